@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretEntry is one NAME=<identifier> pairing for batch mode: Name is the
+// key under which the decoded payload is emitted, and Identifier is a
+// secret short name or full secret path, understood the same way as the
+// single-secret -secret-identifier flag.
+type secretEntry struct {
+	Name       string
+	Identifier string
+}
+
+// identifierList is a repeatable flag.Value collecting -secret-identifier
+// occurrences in order. A bare value (no "=") is a single-secret
+// identifier; a "NAME=<identifier>" value is a batch-mode entry.
+type identifierList []string
+
+func (l *identifierList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *identifierList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseSecretEntry parses one "NAME=<identifier>" line.
+func parseSecretEntry(line string) (secretEntry, error) {
+	name, identifier, ok := strings.Cut(line, "=")
+	if !ok || name == "" || identifier == "" {
+		return secretEntry{}, fmt.Errorf("invalid secret entry %q: expected 'NAME=<identifier>'", line)
+	}
+	return secretEntry{Name: name, Identifier: identifier}, nil
+}
+
+// readSecretsFile parses a -secrets-file: one NAME=<identifier> entry per
+// line; blank lines and lines starting with '#' are ignored.
+func readSecretsFile(path string) ([]secretEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening secrets file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []secretEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseSecretEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("in secrets file '%s': %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading secrets file '%s': %w", path, err)
+	}
+	return entries, nil
+}
+
+// fetchSecretEntries fetches the secret for each entry concurrently,
+// bounded by concurrency, and returns the decoded payloads keyed by entry
+// name. It fails fast: the first error is returned once all in-flight
+// fetches have settled. cfg.timeout, if set, bounds each individual fetch
+// rather than the whole batch. If requireEnabledArg is set, each entry's
+// secret version must also be in the ENABLED state, the same guarantee
+// -require-enabled gives the single-secret path.
+func fetchSecretEntries(ctx context.Context, client SecretClient, entries []secretEntry, version string, concurrency int, requireEnabledArg bool, cfg clientConfig) (map[string][]byte, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	values := make(map[string][]byte, len(entries))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry secretEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			failed := firstErr != nil
+			mu.Unlock()
+			if failed {
+				return
+			}
+
+			fullName, err := buildFullSecretVersionName(ctx, entry.Identifier, version)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("resolving secret %q: %w", entry.Name, err)
+				}
+				mu.Unlock()
+				return
+			}
+
+			payload, err := accessSecret(ctx, client, fullName, cfg)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("fetching secret %q: %w", entry.Name, err)
+				}
+				mu.Unlock()
+				return
+			}
+
+			if requireEnabledArg {
+				if err := requireEnabled(ctx, client, fullName, cfg); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("fetching secret %q: %w", entry.Name, err)
+					}
+					mu.Unlock()
+					return
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			values[entry.Name] = payload
+		}(entry)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return values, nil
+}
+
+// formatSecrets renders values (keyed by entry name) in the given output
+// format. names gives a stable, caller-chosen ordering for formats where
+// order matters.
+func formatSecrets(format string, names []string, values map[string][]byte) ([]byte, error) {
+	switch format {
+	case "raw":
+		if len(names) != 1 {
+			return nil, fmt.Errorf("-output=raw only supports a single secret, got %d", len(names))
+		}
+		return values[names[0]], nil
+	case "dotenv":
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s=%s\n", name, dotenvQuote(string(values[name])))
+		}
+		return []byte(b.String()), nil
+	case "json":
+		out := make(map[string]string, len(names))
+		for _, name := range names {
+			out[name] = string(values[name])
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling JSON output: %w", err)
+		}
+		return append(data, '\n'), nil
+	case "yaml":
+		out := make(map[string]string, len(names))
+		for _, name := range names {
+			out[name] = string(values[name])
+		}
+		data, err := yaml.Marshal(out)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling YAML output: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported -output format %q: expected raw, dotenv, json, or yaml", format)
+	}
+}
+
+// dotenvQuote escapes value for safe inclusion in a dotenv-format line. If
+// it contains a backslash, double quote, or newline, it is wrapped in
+// double quotes with those characters escaped.
+func dotenvQuote(value string) string {
+	if !strings.ContainsAny(value, "\n\\\"") {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + escaped + `"`
+}
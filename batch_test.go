@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// TestParseSecretEntry tests the parseSecretEntry helper.
+func TestParseSecretEntry(t *testing.T) {
+	testCases := []struct {
+		name           string
+		line           string
+		wantEntry      secretEntry
+		wantErr        bool
+		wantErrMsgPart string
+	}{
+		{
+			name:      "simple",
+			line:      "DB_PASSWORD=my-db-password",
+			wantEntry: secretEntry{Name: "DB_PASSWORD", Identifier: "my-db-password"},
+		},
+		{
+			name:      "full path identifier",
+			line:      "API_KEY=projects/p/secrets/api-key/versions/3",
+			wantEntry: secretEntry{Name: "API_KEY", Identifier: "projects/p/secrets/api-key/versions/3"},
+		},
+		{
+			name:           "missing equals",
+			line:           "DB_PASSWORD",
+			wantErr:        true,
+			wantErrMsgPart: "invalid secret entry",
+		},
+		{
+			name:           "empty name",
+			line:           "=my-secret",
+			wantErr:        true,
+			wantErrMsgPart: "invalid secret entry",
+		},
+		{
+			name:           "empty identifier",
+			line:           "DB_PASSWORD=",
+			wantErr:        true,
+			wantErrMsgPart: "invalid secret entry",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, err := parseSecretEntry(tc.line)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErrMsgPart) {
+					t.Fatalf("expected error containing %q, got: %v", tc.wantErrMsgPart, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if entry != tc.wantEntry {
+				t.Errorf("expected entry %+v, got %+v", tc.wantEntry, entry)
+			}
+		})
+	}
+}
+
+// TestReadSecretsFile tests parsing of a -secrets-file.
+func TestReadSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.txt")
+	content := "# a comment\n\nDB_PASSWORD=my-db-password\nAPI_KEY=projects/p/secrets/api-key\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write test secrets file: %v", err)
+	}
+
+	entries, err := readSecretsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []secretEntry{
+		{Name: "DB_PASSWORD", Identifier: "my-db-password"},
+		{Name: "API_KEY", Identifier: "projects/p/secrets/api-key"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(want), len(entries), entries)
+	}
+	for i, entry := range entries {
+		if entry != want[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], entry)
+		}
+	}
+}
+
+// TestReadSecretsFileMissing tests that a missing file produces an error.
+func TestReadSecretsFileMissing(t *testing.T) {
+	_, err := readSecretsFile(filepath.Join(t.TempDir(), "does-not-exist.txt"))
+	if err == nil {
+		t.Fatal("expected an error for a missing secrets file")
+	}
+}
+
+// TestFetchSecretEntries tests fetchSecretEntries against a fakeSecretClient.
+func TestFetchSecretEntries(t *testing.T) {
+	ctx := context.Background()
+	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+
+	client := &fakeSecretClient{
+		secrets: map[string]string{
+			"projects/test-project/secrets/db-password/versions/latest": "hunter2",
+			"projects/test-project/secrets/api-key/versions/latest":     "abc123",
+		},
+	}
+	entries := []secretEntry{
+		{Name: "DB_PASSWORD", Identifier: "db-password"},
+		{Name: "API_KEY", Identifier: "api-key"},
+	}
+
+	values, err := fetchSecretEntries(ctx, client, entries, "", 2, false, clientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(values["DB_PASSWORD"]) != "hunter2" || string(values["API_KEY"]) != "abc123" {
+		t.Errorf("unexpected values: %+v", values)
+	}
+}
+
+// TestFetchSecretEntriesRequireEnabled checks that requireEnabledArg rejects
+// a resolved secret version that is not ENABLED, the same guarantee
+// -require-enabled gives the single-secret path (see TestRunRequireEnabled).
+func TestFetchSecretEntriesRequireEnabled(t *testing.T) {
+	ctx := context.Background()
+
+	const fullName = "projects/p/secrets/my-secret/versions/7"
+	client := &fakeSecretClient{
+		secrets:       map[string]string{fullName: "s3cr3t"},
+		versionStates: map[string]secretmanagerpb.SecretVersion_State{fullName: secretmanagerpb.SecretVersion_DISABLED},
+	}
+	entries := []secretEntry{{Name: "MY_SECRET", Identifier: fullName}}
+
+	_, err := fetchSecretEntries(ctx, client, entries, "", 2, true, clientConfig{})
+	if err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Fatalf("expected an error mentioning the version is not enabled, got: %v", err)
+	}
+}
+
+// TestFetchSecretEntriesError tests that a single failing entry fails the
+// whole batch.
+func TestFetchSecretEntriesError(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSecretClient{secrets: map[string]string{}}
+	entries := []secretEntry{{Name: "MISSING", Identifier: "projects/p/secrets/missing"}}
+
+	_, err := fetchSecretEntries(ctx, client, entries, "", 2, false, clientConfig{})
+	if err == nil || !strings.Contains(err.Error(), "MISSING") {
+		t.Fatalf("expected an error mentioning the failing entry name, got: %v", err)
+	}
+}
+
+// TestFormatSecrets tests the supported -output formats.
+func TestFormatSecrets(t *testing.T) {
+	values := map[string][]byte{
+		"DB_PASSWORD": []byte("hunter2"),
+		"API_KEY":     []byte("abc123"),
+	}
+	names := []string{"DB_PASSWORD", "API_KEY"}
+
+	testCases := []struct {
+		name       string
+		format     string
+		names      []string
+		wantOutput string
+		wantErr    bool
+	}{
+		{
+			name:       "raw",
+			format:     "raw",
+			names:      []string{"DB_PASSWORD"},
+			wantOutput: "hunter2",
+		},
+		{
+			name:    "raw rejects multiple secrets",
+			format:  "raw",
+			names:   names,
+			wantErr: true,
+		},
+		{
+			name:       "dotenv",
+			format:     "dotenv",
+			names:      names,
+			wantOutput: "DB_PASSWORD=hunter2\nAPI_KEY=abc123\n",
+		},
+		{
+			name:       "json",
+			format:     "json",
+			names:      []string{"DB_PASSWORD"},
+			wantOutput: "{\n  \"DB_PASSWORD\": \"hunter2\"\n}\n",
+		},
+		{
+			name:    "unsupported format",
+			format:  "xml",
+			names:   []string{"DB_PASSWORD"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := formatSecrets(tc.format, tc.names, values)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(output) != tc.wantOutput {
+				t.Errorf("expected output %q, got %q", tc.wantOutput, string(output))
+			}
+		})
+	}
+}
+
+// TestDotenvQuote tests that values needing escaping are quoted.
+func TestDotenvQuote(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "abc123", want: "abc123"},
+		{name: "newline", value: "line1\nline2", want: `"line1\nline2"`},
+		{name: "backslash", value: `a\b`, want: `"a\\b"`},
+		{name: "quote", value: `a"b`, want: `"a\"b"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := dotenvQuote(tc.value); got != tc.want {
+				t.Errorf("dotenvQuote(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
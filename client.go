@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+)
+
+// toolVersion is reported in the default User-Agent header
+// (cf-gcp-secret-step/<version>) sent with every Secret Manager API call.
+const toolVersion = "dev"
+
+// clientConfig holds the Secret Manager client options shared by every
+// mode (single-secret, batch, and exec), so they're all constructed and
+// called the same way through newClient and retryCallOptions.
+type clientConfig struct {
+	quotaProject   string
+	endpoint       string
+	universeDomain string
+	userAgent      string
+	timeout        time.Duration
+	maxRetries     int
+}
+
+// newClient constructs a Secret Manager client configured per cfg.
+func newClient(ctx context.Context, cfg clientConfig) (*secretmanager.Client, error) {
+	opts := []option.ClientOption{option.WithUserAgent(cfg.userAgentOrDefault())}
+	if cfg.quotaProject != "" {
+		opts = append(opts, option.WithQuotaProject(cfg.quotaProject))
+	}
+	if cfg.endpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.endpoint))
+	}
+	if cfg.universeDomain != "" {
+		opts = append(opts, option.WithUniverseDomain(cfg.universeDomain))
+	}
+	return secretmanager.NewClient(ctx, opts...)
+}
+
+// userAgentOrDefault returns cfg.userAgent, or "cf-gcp-secret-step/<version>"
+// if it's unset.
+func (cfg clientConfig) userAgentOrDefault() string {
+	if cfg.userAgent != "" {
+		return cfg.userAgent
+	}
+	return fmt.Sprintf("cf-gcp-secret-step/%s", toolVersion)
+}
+
+// withTimeout returns a context bounded by cfg.timeout and its cancel
+// function, or ctx unchanged with a no-op cancel if no timeout is
+// configured.
+func (cfg clientConfig) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cfg.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, cfg.timeout)
+}
+
+// retryableCodes are the gRPC codes worth retrying: transient
+// unavailability, deadline overruns, and throttling.
+var retryableCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted}
+
+// retryCallOptions builds the gax.CallOption applied to each Secret
+// Manager API call: exponential backoff retrying retryableCodes, bounded
+// to cfg.maxRetries retries after the first attempt. Returns nil if retries are disabled
+// (maxRetries <= 0).
+func (cfg clientConfig) retryCallOptions() []gax.CallOption {
+	if cfg.maxRetries <= 0 {
+		return nil
+	}
+	maxRetries := cfg.maxRetries
+	backoff := gax.Backoff{Initial: 250 * time.Millisecond, Max: 10 * time.Second, Multiplier: 2}
+	return []gax.CallOption{
+		gax.WithRetry(func() gax.Retryer {
+			return &boundedRetryer{inner: gax.OnCodes(retryableCodes, backoff), remaining: maxRetries}
+		}),
+	}
+}
+
+// boundedRetryer wraps a gax.Retryer to additionally cap the number of
+// retries, since gax.OnCodes alone retries on the listed codes until the
+// context is done.
+type boundedRetryer struct {
+	inner     gax.Retryer
+	remaining int
+}
+
+func (r *boundedRetryer) Retry(err error) (time.Duration, bool) {
+	if r.remaining <= 0 {
+		return 0, false
+	}
+	pause, shouldRetry := r.inner.Retry(err)
+	if !shouldRetry {
+		return 0, false
+	}
+	r.remaining--
+	return pause, true
+}
+
+// extractFlagValue does a lightweight manual scan of args for a flag by
+// name (accepting "-name value" or "-name=value", with one or two leading
+// dashes). Used to read client-construction flags before a SecretClient
+// exists in main, ahead of the full flag.FlagSet parse in run.
+func extractFlagValue(args []string, name string) (string, bool) {
+	dash, doubleDash := "-"+name, "--"+name
+	for i, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+		if key != dash && key != doubleDash {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// flagOrEnv returns the value of -name (or --name) in args if present,
+// otherwise the value of the envVar environment variable.
+func flagOrEnv(args []string, name, envVar string) string {
+	if v, ok := extractFlagValue(args, name); ok {
+		return v
+	}
+	return os.Getenv(envVar)
+}
+
+// parseClientConfig builds a clientConfig from args with environment
+// variable fallbacks. It mirrors the -quota-project, -endpoint,
+// -universe-domain, -user-agent, -timeout, and -max-retries flags
+// registered in run's flag.FlagSet, but runs before a SecretClient exists,
+// so main can construct one already configured. It returns an error if
+// -timeout or -max-retries is present but unparseable, the same way
+// runExecMode's flag.FlagSet parse does, rather than silently falling back
+// to a zero value.
+func parseClientConfig(args []string) (clientConfig, error) {
+	var cfg clientConfig
+
+	cfg.quotaProject = flagOrEnv(args, "quota-project", "SECRET_MANAGER_QUOTA_PROJECT")
+	cfg.endpoint = flagOrEnv(args, "endpoint", "SECRET_MANAGER_ENDPOINT")
+	cfg.universeDomain = flagOrEnv(args, "universe-domain", "SECRET_MANAGER_UNIVERSE_DOMAIN")
+	cfg.userAgent = flagOrEnv(args, "user-agent", "SECRET_MANAGER_USER_AGENT")
+
+	if v := flagOrEnv(args, "timeout", "SECRET_MANAGER_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return clientConfig{}, fmt.Errorf("invalid -timeout %q: %w", v, err)
+		}
+		cfg.timeout = d
+	}
+	if v := flagOrEnv(args, "max-retries", "SECRET_MANAGER_MAX_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return clientConfig{}, fmt.Errorf("invalid -max-retries %q: %w", v, err)
+		}
+		cfg.maxRetries = n
+	}
+	return cfg, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+)
+
+// TestExtractFlagValue tests the manual flag scan used before a SecretClient
+// exists in main, ahead of the full flag.FlagSet parse in run.
+func TestExtractFlagValue(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		flag      string
+		wantValue string
+		wantOK    bool
+	}{
+		{name: "absent", args: []string{"-secret-identifier", "my-secret"}, flag: "quota-project", wantValue: "", wantOK: false},
+		{name: "space separated", args: []string{"-quota-project", "billing-project", "-secret-identifier", "my-secret"}, flag: "quota-project", wantValue: "billing-project", wantOK: true},
+		{name: "equals form", args: []string{"-quota-project=billing-project"}, flag: "quota-project", wantValue: "billing-project", wantOK: true},
+		{name: "double dash", args: []string{"--quota-project", "billing-project"}, flag: "quota-project", wantValue: "billing-project", wantOK: true},
+		{name: "different flag name", args: []string{"-endpoint", "example.com:443"}, flag: "endpoint", wantValue: "example.com:443", wantOK: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, ok := extractFlagValue(tc.args, tc.flag)
+			if value != tc.wantValue || ok != tc.wantOK {
+				t.Errorf("extractFlagValue(%v, %q) = (%q, %v), want (%q, %v)", tc.args, tc.flag, value, ok, tc.wantValue, tc.wantOK)
+			}
+		})
+	}
+}
+
+// TestFlagOrEnv checks that a flag value takes priority over its
+// corresponding environment variable.
+func TestFlagOrEnv(t *testing.T) {
+	t.Setenv("SECRET_MANAGER_QUOTA_PROJECT", "env-project")
+
+	if got := flagOrEnv([]string{"-quota-project", "flag-project"}, "quota-project", "SECRET_MANAGER_QUOTA_PROJECT"); got != "flag-project" {
+		t.Errorf("expected flag value to win, got %q", got)
+	}
+	if got := flagOrEnv(nil, "quota-project", "SECRET_MANAGER_QUOTA_PROJECT"); got != "env-project" {
+		t.Errorf("expected env fallback, got %q", got)
+	}
+}
+
+// TestParseClientConfig checks that parseClientConfig reads every client
+// option flag, with numeric/duration fields parsed and invalid values
+// rejected.
+func TestParseClientConfig(t *testing.T) {
+	cfg, err := parseClientConfig([]string{
+		"-quota-project", "billing-project",
+		"-endpoint", "secretmanager.googleapis.com:443",
+		"-universe-domain", "googleapis.com",
+		"-user-agent", "my-tool/1.0",
+		"-timeout", "5s",
+		"-max-retries", "3",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := clientConfig{
+		quotaProject:   "billing-project",
+		endpoint:       "secretmanager.googleapis.com:443",
+		universeDomain: "googleapis.com",
+		userAgent:      "my-tool/1.0",
+		timeout:        5 * time.Second,
+		maxRetries:     3,
+	}
+	if cfg != want {
+		t.Errorf("parseClientConfig() = %+v, want %+v", cfg, want)
+	}
+
+	t.Run("invalid timeout is rejected", func(t *testing.T) {
+		if _, err := parseClientConfig([]string{"-timeout", "not-a-duration"}); err == nil {
+			t.Error("expected an error for an invalid -timeout")
+		}
+	})
+
+	t.Run("invalid max-retries is rejected", func(t *testing.T) {
+		if _, err := parseClientConfig([]string{"-max-retries", "not-a-number"}); err == nil {
+			t.Error("expected an error for an invalid -max-retries")
+		}
+	})
+}
+
+// TestUserAgentOrDefault checks the cf-gcp-secret-step/<version> fallback.
+func TestUserAgentOrDefault(t *testing.T) {
+	if got := (clientConfig{}).userAgentOrDefault(); got != "cf-gcp-secret-step/"+toolVersion {
+		t.Errorf("expected default user agent, got %q", got)
+	}
+	if got := (clientConfig{userAgent: "custom/1.0"}).userAgentOrDefault(); got != "custom/1.0" {
+		t.Errorf("expected custom user agent to be returned verbatim, got %q", got)
+	}
+}
+
+// TestClientConfigWithTimeout checks that withTimeout only bounds the
+// context when a timeout is configured.
+func TestClientConfigWithTimeout(t *testing.T) {
+	ctx, cancel := (clientConfig{}).withTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline when timeout is unset")
+	}
+
+	ctx, cancel = (clientConfig{timeout: time.Minute}).withTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("expected a deadline when timeout is set")
+	}
+}
+
+// TestBoundedRetryerRetry checks that boundedRetryer stops retrying once its
+// budget is exhausted, even if the wrapped retryer would keep going.
+func TestBoundedRetryerRetry(t *testing.T) {
+	r := &boundedRetryer{inner: alwaysRetry{}, remaining: 2}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := r.Retry(errors.New("transient")); !ok {
+			t.Fatalf("expected retry %d to be allowed", i)
+		}
+	}
+	if _, ok := r.Retry(errors.New("transient")); ok {
+		t.Error("expected retry budget to be exhausted")
+	}
+}
+
+// alwaysRetry is a gax.Retryer that always requests a retry, used to isolate
+// boundedRetryer's own budget logic from gax.OnCodes' behavior.
+type alwaysRetry struct{}
+
+func (alwaysRetry) Retry(err error) (time.Duration, bool) { return 0, true }
+
+var _ gax.Retryer = alwaysRetry{}
+
+// TestAccessSecretTimeoutIsPerCall checks that cfg.timeout bounds a single
+// accessSecret call rather than being shared across a batch: a client that
+// blocks past the deadline sees its own call time out, while a second,
+// independent call with the same cfg still gets its own full budget.
+func TestAccessSecretTimeoutIsPerCall(t *testing.T) {
+	ctx := context.Background()
+	cfg := clientConfig{timeout: 10 * time.Millisecond}
+
+	slowClient := &fakeSecretClient{waitForCancel: true}
+	if _, err := accessSecret(ctx, slowClient, "projects/p/secrets/s/versions/latest", cfg); err == nil {
+		t.Fatal("expected the blocked call to time out")
+	}
+
+	fastClient := &fakeSecretClient{secrets: map[string]string{"projects/p/secrets/s/versions/latest": "s3cr3t"}}
+	payload, err := accessSecret(ctx, fastClient, "projects/p/secrets/s/versions/latest", cfg)
+	if err != nil {
+		t.Fatalf("expected a fresh per-call timeout budget, got: %v", err)
+	}
+	if string(payload) != "s3cr3t" {
+		t.Errorf("expected payload %q, got %q", "s3cr3t", payload)
+	}
+}
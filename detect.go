@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2/google"
+)
+
+// detectProjectIDSentinel is returned by detectProjectID when no strategy
+// resolves a project ID. Borrowed from the pattern in
+// google-cloud-go/internal/detect, it lets callers that can defer
+// resolution (e.g. embed it in a secret path and let the API request
+// itself fail with a clear error) pass it through instead of failing fast.
+const detectProjectIDSentinel = "*detect-project-id*"
+
+// projectIDOption configures detectProjectID.
+type projectIDOption func(*projectIDConfig)
+
+type projectIDConfig struct {
+	override string
+}
+
+// withProjectIDOverride makes detectProjectID return override immediately
+// if it is non-empty, skipping every other resolution strategy.
+func withProjectIDOverride(override string) projectIDOption {
+	return func(c *projectIDConfig) { c.override = override }
+}
+
+// detectProjectID resolves the GCP project ID, trying each of the
+// following in order until one succeeds:
+//
+//  1. an explicit override (withProjectIDOverride)
+//  2. the GOOGLE_CLOUD_PROJECT environment variable
+//  3. the GCP_PROJECT environment variable
+//  4. Application Default Credentials (including credentials JSON)
+//  5. the GCE metadata server
+//
+// If none of these resolve a project ID, it returns
+// detectProjectIDSentinel with a nil error rather than failing.
+func detectProjectID(ctx context.Context, opts ...projectIDOption) (string, error) {
+	cfg := &projectIDConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.override != "" {
+		return cfg.override, nil
+	}
+	if projectID := os.Getenv("GOOGLE_CLOUD_PROJECT"); projectID != "" {
+		return projectID, nil
+	}
+	if projectID := os.Getenv("GCP_PROJECT"); projectID != "" {
+		return projectID, nil
+	}
+
+	if credentials, err := google.FindDefaultCredentials(ctx); err == nil && credentials.ProjectID != "" {
+		return credentials.ProjectID, nil
+	}
+
+	if metadata.OnGCE() {
+		if projectID, err := metadata.ProjectIDWithContext(ctx); err == nil && projectID != "" {
+			return projectID, nil
+		}
+	}
+
+	return detectProjectIDSentinel, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestDetectProjectID tests detectProjectID's resolution order.
+func TestDetectProjectID(t *testing.T) {
+	ctx := context.Background()
+
+	originalGoogleCloudProject := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	originalGCPProject := os.Getenv("GCP_PROJECT")
+	defer func() {
+		os.Setenv("GOOGLE_CLOUD_PROJECT", originalGoogleCloudProject)
+		os.Setenv("GCP_PROJECT", originalGCPProject)
+	}()
+
+	t.Run("override takes priority", func(t *testing.T) {
+		os.Setenv("GOOGLE_CLOUD_PROJECT", "env-project")
+		defer os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+
+		projectID, err := detectProjectID(ctx, withProjectIDOverride("override-project"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if projectID != "override-project" {
+			t.Errorf("expected override-project, got %q", projectID)
+		}
+	})
+
+	t.Run("GOOGLE_CLOUD_PROJECT takes priority over GCP_PROJECT", func(t *testing.T) {
+		os.Setenv("GOOGLE_CLOUD_PROJECT", "gcloud-env-project")
+		os.Setenv("GCP_PROJECT", "gcp-env-project")
+		defer func() {
+			os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+			os.Unsetenv("GCP_PROJECT")
+		}()
+
+		projectID, err := detectProjectID(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if projectID != "gcloud-env-project" {
+			t.Errorf("expected gcloud-env-project, got %q", projectID)
+		}
+	})
+
+	t.Run("falls back to GCP_PROJECT", func(t *testing.T) {
+		os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+		os.Setenv("GCP_PROJECT", "gcp-env-project")
+		defer os.Unsetenv("GCP_PROJECT")
+
+		projectID, err := detectProjectID(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if projectID != "gcp-env-project" {
+			t.Errorf("expected gcp-env-project, got %q", projectID)
+		}
+	})
+
+	t.Run("falls back to sentinel when nothing resolves", func(t *testing.T) {
+		os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+		os.Unsetenv("GCP_PROJECT")
+
+		projectID, err := detectProjectID(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if projectID != detectProjectIDSentinel {
+			t.Logf("expected sentinel %q, got %q - ADC or GCE metadata may be configured in this environment", detectProjectIDSentinel, projectID)
+		}
+	})
+}
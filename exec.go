@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretEnvPattern matches environment variable values shaped like
+// gcp:secretmanager:<short-name> or
+// gcp:secretmanager:projects/P/secrets/S[/versions/V].
+var secretEnvPattern = regexp.MustCompile(`^gcp:secretmanager:(.+)$`)
+
+// splitEnv splits a "KEY=VALUE" environment entry into its key and value.
+func splitEnv(kv string) (key, value string, ok bool) {
+	return strings.Cut(kv, "=")
+}
+
+// resolveSecretEnv scans environ (a slice of "KEY=VALUE" strings, as
+// returned by os.Environ) for values referencing gcp:secretmanager: secrets,
+// fetches each unique referenced secret in parallel, and returns a new
+// environment slice with the references replaced by the decoded secret
+// payloads. It fails fast if any referenced secret cannot be resolved.
+func resolveSecretEnv(ctx context.Context, client SecretClient, environ []string, cfg clientConfig) ([]string, error) {
+	identifiers := make(map[string]struct{})
+	for _, kv := range environ {
+		_, value, ok := splitEnv(kv)
+		if !ok {
+			continue
+		}
+		if m := secretEnvPattern.FindStringSubmatch(value); m != nil {
+			identifiers[m[1]] = struct{}{}
+		}
+	}
+	if len(identifiers) == 0 {
+		return environ, nil
+	}
+
+	payloads, err := fetchSecrets(ctx, client, identifiers, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]string, len(environ))
+	for i, kv := range environ {
+		key, value, ok := splitEnv(kv)
+		if !ok {
+			resolved[i] = kv
+			continue
+		}
+		m := secretEnvPattern.FindStringSubmatch(value)
+		if m == nil {
+			resolved[i] = kv
+			continue
+		}
+		resolved[i] = fmt.Sprintf("%s=%s", key, payloads[m[1]])
+	}
+	return resolved, nil
+}
+
+// fetchSecrets resolves and fetches one secret per identifier in parallel,
+// reusing buildFullSecretVersionName (and its project autodetection) for the
+// short-name form. It waits for every fetch to complete, then returns the
+// payloads, or the first error encountered if any fetch failed; a failure
+// does not cancel the other fetches. cfg.timeout, if set, bounds each individual fetch
+// rather than the whole set.
+func fetchSecrets(ctx context.Context, client SecretClient, identifiers map[string]struct{}, cfg clientConfig) (map[string][]byte, error) {
+	payloads := make(map[string][]byte, len(identifiers))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for identifier := range identifiers {
+		wg.Add(1)
+		go func(identifier string) {
+			defer wg.Done()
+
+			fullName, err := buildFullSecretVersionName(ctx, identifier, "")
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("resolving secret reference %q: %w", identifier, err)
+				}
+				mu.Unlock()
+				return
+			}
+
+			payload, err := accessSecret(ctx, client, fullName, cfg)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("fetching secret %q: %w", identifier, err)
+				}
+				return
+			}
+			payloads[identifier] = payload
+		}(identifier)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return payloads, nil
+}
+
+// runExecMode implements the "exec" subcommand: it resolves
+// gcp:secretmanager: references found in the current process environment
+// and then execs the given command with the resolved environment, e.g.
+// `cf-gcp-secret-step exec -- /bin/my-app arg1`.
+func runExecMode(args []string) {
+	fs := flag.NewFlagSet("cf-gcp-secret-step exec", flag.ContinueOnError)
+	var quotaProjectArg string
+	fs.StringVar(&quotaProjectArg, "quota-project", "", "GCP project to bill API usage to")
+	var endpointArg string
+	fs.StringVar(&endpointArg, "endpoint", "", "Secret Manager API endpoint override")
+	var universeDomainArg string
+	fs.StringVar(&universeDomainArg, "universe-domain", "", "GCP universe domain")
+	var userAgentArg string
+	fs.StringVar(&userAgentArg, "user-agent", "", "User-Agent header sent with API requests")
+	var timeoutArg string
+	fs.StringVar(&timeoutArg, "timeout", "", "Per-request timeout (e.g. '30s')")
+	var maxRetriesArg int
+	fs.IntVar(&maxRetriesArg, "max-retries", 0, "Maximum number of retries for retryable API errors")
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	command := fs.Args()
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: exec mode requires a command, e.g. 'cf-gcp-secret-step exec -- /bin/my-app arg1'")
+		os.Exit(1)
+	}
+
+	// runExecMode parses its own flags before determining command (via
+	// fs.Args()), so unlike run, it can populate clientConfig directly from
+	// flag vars instead of needing the manual pre-scan in parseClientConfig.
+	cfg := clientConfig{
+		quotaProject:   quotaProjectArg,
+		endpoint:       endpointArg,
+		universeDomain: universeDomainArg,
+		userAgent:      userAgentArg,
+		maxRetries:     maxRetriesArg,
+	}
+	if timeoutArg != "" {
+		d, err := time.ParseDuration(timeoutArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -timeout %q: %v\n", timeoutArg, err)
+			os.Exit(1)
+		}
+		cfg.timeout = d
+	}
+
+	ctx := context.Background()
+
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating Secret Manager client: %v\n", err)
+		os.Exit(2)
+	}
+	defer client.Close()
+
+	resolvedEnv, err := resolveSecretEnv(ctx, client, os.Environ(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(3)
+	}
+
+	if err := execCommand(command[0], command, resolvedEnv); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(4)
+	}
+}
+
+// lookPath resolves cmd to an executable path, searching PATH if cmd does
+// not already contain a path separator.
+func lookPath(cmd string) (string, error) {
+	return exec.LookPath(cmd)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSplitEnv tests the splitEnv helper.
+func TestSplitEnv(t *testing.T) {
+	testCases := []struct {
+		name      string
+		kv        string
+		wantKey   string
+		wantValue string
+		wantOK    bool
+	}{
+		{
+			name:      "simple",
+			kv:        "FOO=bar",
+			wantKey:   "FOO",
+			wantValue: "bar",
+			wantOK:    true,
+		},
+		{
+			name:      "value contains equals",
+			kv:        "FOO=bar=baz",
+			wantKey:   "FOO",
+			wantValue: "bar=baz",
+			wantOK:    true,
+		},
+		{
+			name:   "no equals",
+			kv:     "FOO",
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, value, ok := splitEnv(tc.kv)
+			if ok != tc.wantOK {
+				t.Fatalf("splitEnv(%q): expected ok=%v, got %v", tc.kv, tc.wantOK, ok)
+			}
+			if !tc.wantOK {
+				return
+			}
+			if key != tc.wantKey || value != tc.wantValue {
+				t.Errorf("splitEnv(%q) = (%q, %q), want (%q, %q)", tc.kv, key, value, tc.wantKey, tc.wantValue)
+			}
+		})
+	}
+}
+
+// TestSecretEnvPattern tests which environment variable values are
+// recognized as gcp:secretmanager: references.
+func TestSecretEnvPattern(t *testing.T) {
+	testCases := []struct {
+		name      string
+		value     string
+		wantMatch bool
+		wantIdent string
+	}{
+		{
+			name:      "short name",
+			value:     "gcp:secretmanager:my-secret",
+			wantMatch: true,
+			wantIdent: "my-secret",
+		},
+		{
+			name:      "full path with version",
+			value:     "gcp:secretmanager:projects/p/secrets/s/versions/3",
+			wantMatch: true,
+			wantIdent: "projects/p/secrets/s/versions/3",
+		},
+		{
+			name:      "unrelated value",
+			value:     "plain-value",
+			wantMatch: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := secretEnvPattern.FindStringSubmatch(tc.value)
+			if tc.wantMatch && m == nil {
+				t.Fatalf("expected %q to match secretEnvPattern", tc.value)
+			}
+			if !tc.wantMatch && m != nil {
+				t.Fatalf("expected %q not to match secretEnvPattern", tc.value)
+			}
+			if tc.wantMatch && m[1] != tc.wantIdent {
+				t.Errorf("expected identifier %q, got %q", tc.wantIdent, m[1])
+			}
+		})
+	}
+}
+
+// TestFetchSecrets tests fetchSecrets against a fakeSecretClient.
+func TestFetchSecrets(t *testing.T) {
+	ctx := context.Background()
+	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+
+	client := &fakeSecretClient{
+		secrets: map[string]string{
+			"projects/test-project/secrets/db-password/versions/latest": "hunter2",
+			"projects/test-project/secrets/api-key/versions/latest":     "abc123",
+		},
+	}
+	identifiers := map[string]struct{}{"db-password": {}, "api-key": {}}
+
+	payloads, err := fetchSecrets(ctx, client, identifiers, clientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payloads["db-password"]) != "hunter2" || string(payloads["api-key"]) != "abc123" {
+		t.Errorf("unexpected payloads: %+v", payloads)
+	}
+}
+
+// TestFetchSecretsError tests that a single unresolvable identifier fails
+// the whole fetch.
+func TestFetchSecretsError(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSecretClient{secrets: map[string]string{}}
+	identifiers := map[string]struct{}{"projects/p/secrets/missing": {}}
+
+	_, err := fetchSecrets(ctx, client, identifiers, clientConfig{})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected an error mentioning the missing secret, got: %v", err)
+	}
+}
+
+// TestResolveSecretEnv tests that resolveSecretEnv replaces
+// gcp:secretmanager: references with fetched payloads and leaves unrelated
+// entries untouched.
+func TestResolveSecretEnv(t *testing.T) {
+	ctx := context.Background()
+	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+
+	client := &fakeSecretClient{
+		secrets: map[string]string{
+			"projects/test-project/secrets/db-password/versions/latest": "hunter2",
+		},
+	}
+	environ := []string{
+		"DB_PASSWORD=gcp:secretmanager:db-password",
+		"PATH=/usr/bin",
+	}
+
+	resolved, err := resolveSecretEnv(ctx, client, environ, clientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resolved[0] != "DB_PASSWORD=hunter2" {
+		t.Errorf("expected DB_PASSWORD to be resolved, got %q", resolved[0])
+	}
+	if resolved[1] != "PATH=/usr/bin" {
+		t.Errorf("expected unrelated entry to be left untouched, got %q", resolved[1])
+	}
+}
+
+// TestResolveSecretEnvError tests that an unresolvable secret reference
+// fails resolveSecretEnv.
+func TestResolveSecretEnvError(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSecretClient{secrets: map[string]string{}}
+	environ := []string{"DB_PASSWORD=gcp:secretmanager:projects/p/secrets/missing"}
+
+	if _, err := resolveSecretEnv(ctx, client, environ, clientConfig{}); err == nil {
+		t.Fatal("expected an error for an unresolvable secret reference")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// execCommand replaces the current process image with cmd, so the child
+// inherits our pid and its exit code becomes our exit code directly. On
+// success it never returns.
+func execCommand(cmd string, argv []string, env []string) error {
+	path, err := lookPath(cmd)
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, env)
+}
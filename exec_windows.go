@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// execCommand runs cmd as a child process with stdio passed through, since
+// Windows has no direct equivalent of POSIX exec(). It preserves the
+// child's exit code by exiting the parent process with the same code once
+// the child finishes.
+func execCommand(cmd string, argv []string, env []string) error {
+	path, err := lookPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	c := exec.Command(path, argv[1:]...)
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if err := c.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -4,81 +4,113 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strings"
 
-	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
-	"golang.org/x/oauth2/google" // For ADC project ID discovery
+	"github.com/googleapis/gax-go/v2"
 )
 
-// getProjectIDFromEnvOrADC tries to determine the GCP project ID.
-// It checks the GOOGLE_CLOUD_PROJECT environment variable first,
-// then tries to get it from Application Default Credentials.
-func getProjectIDFromEnvOrADC(ctx context.Context) (string, error) {
-	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
-	if projectID != "" {
-		return projectID, nil
-	}
-
-	// Try to get Project ID from Application Default Credentials
-	// Note: In a pure unit test environment, this might not find credentials
-	// or a project ID unless the test runner environment is configured with ADC.
-	credentials, err := google.FindDefaultCredentials(ctx, secretmanager.DefaultAuthScopes()...)
-	if err != nil {
-		return "", fmt.Errorf("error finding default Google Cloud credentials: %w", err)
-	}
-
-	if credentials.ProjectID == "" {
-		return "", fmt.Errorf("GOOGLE_CLOUD_PROJECT environment variable is not set, and could not determine Project ID from Application Default Credentials")
-	}
-	return credentials.ProjectID, nil
+// SecretClient is the subset of *secretmanager.Client used by this tool,
+// extracted as an interface so accessSecret and run can be unit-tested
+// against a fake implementation instead of real Application Default
+// Credentials and network access.
+type SecretClient interface {
+	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error)
+	GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error)
+	Close() error
 }
 
-// buildFullSecretVersionName constructs the full secret version name.
-// It's extracted for easier testing.
-func buildFullSecretVersionName(ctx context.Context, secretIdentifier string) (string, error) {
+// versionIDPattern matches a valid /versions/<id> suffix: either the
+// "latest" alias or a numeric version ID.
+var versionIDPattern = regexp.MustCompile(`^(latest|[0-9]+)$`)
+
+// buildFullSecretVersionName constructs the full secret version name for
+// secretIdentifier. It's extracted for easier testing.
+//
+// If secretIdentifier already has an explicit "/versions/<id>" suffix
+// (numeric or "latest"), it is honored verbatim rather than rewritten -
+// callers that pin a version expect that pin to be respected. Otherwise
+// the path (or, for short names, the autodetected project and secret
+// path) is suffixed with version, or "latest" if version is empty.
+func buildFullSecretVersionName(ctx context.Context, secretIdentifier, version string) (string, error) {
 	if secretIdentifier == "" {
 		return "", fmt.Errorf("secret identifier cannot be empty")
 	}
 
-	var fullSecretVersionName string
-
 	if strings.Contains(secretIdentifier, "/") {
 		baseSecretPath := secretIdentifier
+		explicitVersion := ""
 		if strings.Contains(secretIdentifier, "/versions/") {
 			parts := strings.SplitN(secretIdentifier, "/versions/", 2)
-			baseSecretPath = parts[0]
+			baseSecretPath, explicitVersion = parts[0], parts[1]
 		}
 		if !strings.HasPrefix(baseSecretPath, "projects/") || strings.Count(baseSecretPath, "/") < 3 {
 			return "", fmt.Errorf("invalid secret path format for '%s'. Expected 'projects/PROJECT_ID/secrets/SECRET_ID'", secretIdentifier)
 		}
-		fullSecretVersionName = fmt.Sprintf("%s/versions/latest", baseSecretPath)
-	} else {
-		projectID, err := getProjectIDFromEnvOrADC(ctx)
-		if err != nil {
-			return "", fmt.Errorf("could not determine Project ID for secret '%s': %w. To resolve, set GOOGLE_CLOUD_PROJECT, ensure ADC has a project, or use full path", secretIdentifier, err)
+		if explicitVersion != "" {
+			if !versionIDPattern.MatchString(explicitVersion) {
+				return "", fmt.Errorf("invalid version suffix in '%s': expected 'latest' or a numeric version ID", secretIdentifier)
+			}
+			return secretIdentifier, nil
+		}
+		if version == "" {
+			version = "latest"
 		}
-		fullSecretVersionName = fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, secretIdentifier)
+		if !versionIDPattern.MatchString(version) {
+			return "", fmt.Errorf("invalid version '%s': expected 'latest' or a numeric version ID", version)
+		}
+		return fmt.Sprintf("%s/versions/%s", baseSecretPath, version), nil
+	}
+
+	if version == "" {
+		version = "latest"
+	}
+	if !versionIDPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid version '%s': expected 'latest' or a numeric version ID", version)
+	}
+
+	projectID, err := detectProjectID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not determine Project ID for secret '%s': %w. To resolve, set GOOGLE_CLOUD_PROJECT, ensure ADC has a project, or use full path", secretIdentifier, err)
+	}
+	if projectID == detectProjectIDSentinel {
+		return "", fmt.Errorf("could not determine Project ID for secret '%s'. To resolve, set GOOGLE_CLOUD_PROJECT, ensure ADC has a project, or use full path", secretIdentifier)
 	}
-	return fullSecretVersionName, nil
+	return fmt.Sprintf("projects/%s/secrets/%s/versions/%s", projectID, secretIdentifier, version), nil
 }
 
-// accessSecret retrieves the secret value.
-// This function encapsulates the client interaction.
-// For unit testing the main logic, this part would typically be mocked.
-func accessSecret(ctx context.Context, fullSecretVersionName string) ([]byte, error) {
-	client, err := secretmanager.NewClient(ctx)
+// requireEnabled fetches the metadata for fullSecretVersionName and
+// returns an error if its state is not ENABLED, rejecting DISABLED and
+// DESTROYED versions from being used.
+func requireEnabled(ctx context.Context, client SecretClient, fullSecretVersionName string, cfg clientConfig) error {
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
+
+	result, err := client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{Name: fullSecretVersionName}, cfg.retryCallOptions()...)
 	if err != nil {
-		return nil, fmt.Errorf("error creating Secret Manager client: %w", err)
+		return fmt.Errorf("error checking state of secret version '%s': %w", fullSecretVersionName, err)
 	}
-	defer client.Close()
+	if result.State != secretmanagerpb.SecretVersion_ENABLED {
+		return fmt.Errorf("secret version '%s' is not enabled (state: %s)", fullSecretVersionName, result.State)
+	}
+	return nil
+}
+
+// accessSecret retrieves the secret value via client.
+// It's extracted as a thin wrapper around SecretClient so it can be
+// exercised against a fake client in tests.
+func accessSecret(ctx context.Context, client SecretClient, fullSecretVersionName string, cfg clientConfig) ([]byte, error) {
+	ctx, cancel := cfg.withTimeout(ctx)
+	defer cancel()
 
 	req := &secretmanagerpb.AccessSecretVersionRequest{
 		Name: fullSecretVersionName,
 	}
 
-	result, err := client.AccessSecretVersion(ctx, req)
+	result, err := client.AccessSecretVersion(ctx, req, cfg.retryCallOptions()...)
 	if err != nil {
 		return nil, fmt.Errorf("error accessing secret version '%s': %w", fullSecretVersionName, err)
 	}
@@ -89,33 +121,195 @@ func accessSecret(ctx context.Context, fullSecretVersionName string) ([]byte, er
 	return result.Payload.Data, nil
 }
 
-func main() {
-	ctx := context.Background()
+// run implements the CLI flow: fetching a single secret (-secret-identifier
+// as a bare value) or, in batch mode (-secrets-file, or -secret-identifier
+// repeated as 'NAME=<identifier>'), many secrets at once. It is separated
+// from main so it can be exercised in tests against a fake SecretClient and
+// in-memory stdout/stderr, and returns the process exit code rather than
+// calling os.Exit directly.
+func run(ctx context.Context, client SecretClient, cfg clientConfig, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cf-gcp-secret-step", flag.ContinueOnError)
+	fs.SetOutput(stderr)
 
-	var secretIdentifierArg string
-	flag.StringVar(&secretIdentifierArg, "secret-identifier", os.Getenv("SECRET_NAME"), "Secret name (e.g., 'my-secret') or full secret path (e.g., 'projects/PROJECT_ID/secrets/SECRET_ID')")
-	flag.Parse() 
+	var identifiers identifierList
+	fs.Var(&identifiers, "secret-identifier", "Secret name or full secret path (e.g., 'projects/PROJECT_ID/secrets/SECRET_ID') for single-secret mode; repeat as 'NAME=<identifier>' for batch mode (see -secrets-file)")
+	var secretsFileArg string
+	fs.StringVar(&secretsFileArg, "secrets-file", "", "Path to a file of 'NAME=<identifier>' entries, one per line, for batch mode")
+	var versionArg string
+	fs.StringVar(&versionArg, "version", os.Getenv("SECRET_VERSION"), "Secret version to use for short-name or bare-path identifiers (default \"latest\"); ignored if the identifier already has an explicit /versions/ suffix")
+	var requireEnabledArg bool
+	fs.BoolVar(&requireEnabledArg, "require-enabled", false, "Fail if the resolved secret version is not in the ENABLED state")
+	var concurrencyArg int
+	fs.IntVar(&concurrencyArg, "concurrency", 5, "Maximum number of secrets to fetch concurrently in batch mode")
+	var outputArg string
+	fs.StringVar(&outputArg, "output", "", "Output format: raw, dotenv, json, or yaml (default \"raw\" for a single secret, \"dotenv\" in batch mode)")
+	var outputFileArg string
+	fs.StringVar(&outputFileArg, "output-file", "", "Path to write output to (0600 perms) instead of stdout")
+	var quotaProjectArg string
+	fs.StringVar(&quotaProjectArg, "quota-project", "", "GCP project to bill API usage to; already applied to client by the time run is called, declared here so it parses alongside the other flags")
+	var endpointArg string
+	fs.StringVar(&endpointArg, "endpoint", "", "Secret Manager API endpoint override; already applied to client by the time run is called, declared here so it parses alongside the other flags")
+	var universeDomainArg string
+	fs.StringVar(&universeDomainArg, "universe-domain", "", "GCP universe domain; already applied to client by the time run is called, declared here so it parses alongside the other flags")
+	var userAgentArg string
+	fs.StringVar(&userAgentArg, "user-agent", "", "User-Agent header sent with API requests; already applied to client by the time run is called, declared here so it parses alongside the other flags")
+	var timeoutArg string
+	fs.StringVar(&timeoutArg, "timeout", "", "Per-request timeout (e.g. '30s'); already parsed into cfg by the time run is called, declared here so it parses alongside the other flags")
+	var maxRetriesArg int
+	fs.IntVar(&maxRetriesArg, "max-retries", 0, "Maximum number of retries for retryable API errors; already parsed into cfg by the time run is called, declared here so it parses alongside the other flags")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
 
-	if secretIdentifierArg == "" {
-		fmt.Fprintln(os.Stderr, "Error: The -secret-identifier flag or SECRET_NAME environment variable must be provided and non-empty.")
-		os.Exit(1) 
+	if len(identifiers) == 0 && secretsFileArg == "" {
+		if envIdentifier := os.Getenv("SECRET_NAME"); envIdentifier != "" {
+			identifiers = append(identifiers, envIdentifier)
+		}
 	}
 
-	fullSecretVersionName, err := buildFullSecretVersionName(ctx, secretIdentifierArg)
+	var entries []secretEntry
+	var bareIdentifiers []string
+	for _, ident := range identifiers {
+		if name, value, ok := strings.Cut(ident, "="); ok && name != "" && value != "" {
+			entries = append(entries, secretEntry{Name: name, Identifier: value})
+		} else {
+			bareIdentifiers = append(bareIdentifiers, ident)
+		}
+	}
+	if secretsFileArg != "" {
+		fileEntries, err := readSecretsFile(secretsFileArg)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	batchMode := secretsFileArg != "" || len(entries) > 0 || len(bareIdentifiers) > 1
+	if batchMode {
+		if len(bareIdentifiers) > 0 {
+			fmt.Fprintln(stderr, "Error: bare -secret-identifier values are not allowed in batch mode; use 'NAME=<identifier>' for every entry")
+			return 1
+		}
+		return runBatch(ctx, client, cfg, entries, versionArg, concurrencyArg, requireEnabledArg, outputArg, outputFileArg, stdout, stderr)
+	}
+
+	if len(bareIdentifiers) != 1 || bareIdentifiers[0] == "" {
+		fmt.Fprintln(stderr, "Error: The -secret-identifier flag or SECRET_NAME environment variable must be provided and non-empty.")
+		return 1
+	}
+	secretIdentifierArg := bareIdentifiers[0]
+
+	fullSecretVersionName, err := buildFullSecretVersionName(ctx, secretIdentifierArg, versionArg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(2) 
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
 	}
 
-	secretPayload, err := accessSecret(ctx, fullSecretVersionName)
+	secretPayload, err := accessSecret(ctx, client, fullSecretVersionName, cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 3
+	}
+
+	if requireEnabledArg {
+		if err := requireEnabled(ctx, client, fullSecretVersionName, cfg); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 5
+		}
+	}
+
+	format := outputArg
+	if format == "" {
+		format = "raw"
+	}
+	output, err := formatSecrets(format, []string{"value"}, map[string][]byte{"value": secretPayload})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if err := writeOutput(output, outputFileArg, stdout); err != nil {
+		fmt.Fprintf(stderr, "Error writing secret payload: %v\n", err)
+		return 4
+	}
+	return 0
+}
+
+// writeOutput writes output to outputFilePath (created with 0600 perms) if
+// non-empty, or to stdout otherwise.
+func writeOutput(output []byte, outputFilePath string, stdout io.Writer) error {
+	if outputFilePath == "" {
+		_, err := stdout.Write(output)
+		return err
+	}
+	return os.WriteFile(outputFilePath, output, 0o600)
+}
+
+// runBatch implements the -secrets-file / repeated 'NAME=<identifier>'
+// batch mode: it fetches every entry concurrently and emits the results in
+// the requested structured output format.
+func runBatch(ctx context.Context, client SecretClient, cfg clientConfig, entries []secretEntry, version string, concurrency int, requireEnabledArg bool, outputFormat, outputFilePath string, stdout, stderr io.Writer) int {
+	if len(entries) == 0 {
+		fmt.Fprintln(stderr, "Error: batch mode requires at least one 'NAME=<identifier>' entry via -secret-identifier or -secrets-file")
+		return 1
+	}
+
+	format := outputFormat
+	if format == "" {
+		format = "dotenv"
+	}
+
+	names := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if seen[entry.Name] {
+			fmt.Fprintf(stderr, "Error: duplicate secret entry name %q\n", entry.Name)
+			return 1
+		}
+		seen[entry.Name] = true
+		names = append(names, entry.Name)
+	}
+
+	values, err := fetchSecretEntries(ctx, client, entries, version, concurrency, requireEnabledArg, cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 3
+	}
+
+	output, err := formatSecrets(format, names, values)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if err := writeOutput(output, outputFilePath, stdout); err != nil {
+		fmt.Fprintf(stderr, "Error writing output: %v\n", err)
+		return 4
+	}
+	return 0
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "exec" {
+		runExecMode(os.Args[2:])
+		return
+	}
+
+	ctx := context.Background()
+
+	cfg, err := parseClientConfig(os.Args[1:])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(3) 
+		os.Exit(1)
 	}
 
-	_, err = os.Stdout.Write(secretPayload)
+	client, err := newClient(ctx, cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing secret payload to stdout: %v\n", err)
-		os.Exit(4) 
+		fmt.Fprintf(os.Stderr, "Error creating Secret Manager client: %v\n", err)
+		os.Exit(3)
 	}
+	defer client.Close()
+
+	os.Exit(run(ctx, client, cfg, os.Args[1:], os.Stdout, os.Stderr))
 }
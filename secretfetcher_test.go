@@ -1,38 +1,70 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
 	"strings"
 	"testing"
-)
 
-// TestGetProjectIDFromEnvOrADC tests the getProjectIDFromEnvOrADC function.
-func TestGetProjectIDFromEnvOrADC(t *testing.T) {
-	ctx := context.Background()
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
-	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
-	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+// fakeSecretClient is a SecretClient backed by an in-memory map, used to
+// exercise accessSecret and run without real Application Default
+// Credentials or network access.
+type fakeSecretClient struct {
+	secrets       map[string]string                              // full secret version name -> payload
+	versionStates map[string]secretmanagerpb.SecretVersion_State // full secret version name -> state, for GetSecretVersion
+	nilPayload    bool                                           // if true, responses carry a nil Payload
+	returnError   error                                          // if non-nil, AccessSecretVersion returns this error
+	getStateError error                                          // if non-nil, GetSecretVersion returns this error
+	closeError    error                                          // if non-nil, Close returns this error
+	closed        bool
+	waitForCancel bool // if true, AccessSecretVersion blocks until ctx is done and returns ctx.Err()
+}
 
-	expectedProjectID := "test-project-from-env"
-	os.Setenv("GOOGLE_CLOUD_PROJECT", expectedProjectID)
-	projectID, err := getProjectIDFromEnvOrADC(ctx)
-	if err != nil {
-		t.Errorf("TestGetProjectIDFromEnvOrADC (env set): unexpected error: %v", err)
+func (f *fakeSecretClient) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	if f.waitForCancel {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.returnError != nil {
+		return nil, f.returnError
+	}
+	payload, ok := f.secrets[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret version %q not found", req.Name)
 	}
-	if projectID != expectedProjectID {
-		t.Errorf("TestGetProjectIDFromEnvOrADC (env set): expected project ID '%s', got '%s'", expectedProjectID, projectID)
+	if f.nilPayload {
+		return &secretmanagerpb.AccessSecretVersionResponse{Name: req.Name}, nil
 	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name: req.Name,
+		Payload: &secretmanagerpb.SecretPayload{
+			Data: []byte(payload),
+		},
+	}, nil
+}
 
-	os.Unsetenv("GOOGLE_CLOUD_PROJECT")
-	_, err = getProjectIDFromEnvOrADC(ctx)
-	if err == nil {
-		t.Log("TestGetProjectIDFromEnvOrADC (env unset): received no error, ADC might be configured. This test path assumes ADC also fails.")
-	} else {
-		if !strings.Contains(err.Error(), "GOOGLE_CLOUD_PROJECT environment variable is not set") && !strings.Contains(err.Error(), "Could not determine Project ID from Application Default Credentials") {
-			t.Errorf("TestGetProjectIDFromEnvOrADC (env unset): expected error related to missing project ID, got: %v", err)
-		}
+func (f *fakeSecretClient) GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error) {
+	if f.getStateError != nil {
+		return nil, f.getStateError
 	}
+	state, ok := f.versionStates[req.Name]
+	if !ok {
+		state = secretmanagerpb.SecretVersion_ENABLED
+	}
+	return &secretmanagerpb.SecretVersion{Name: req.Name, State: state}, nil
+}
+
+func (f *fakeSecretClient) Close() error {
+	f.closed = true
+	return f.closeError
 }
 
 // TestBuildFullSecretVersionName tests the buildFullSecretVersionName function.
@@ -44,10 +76,11 @@ func TestBuildFullSecretVersionName(t *testing.T) {
 	testCases := []struct {
 		name             string
 		secretIdentifier string
-		projectEnv       string 
+		version          string
+		projectEnv       string
 		wantName         string
 		wantErr          bool
-		wantErrMsgPart   string 
+		wantErrMsgPart   string
 	}{
 		{
 			name:             "short name with project env",
@@ -56,20 +89,43 @@ func TestBuildFullSecretVersionName(t *testing.T) {
 			wantName:         "projects/test-project-1/secrets/my-secret/versions/latest",
 			wantErr:          false,
 		},
+		{
+			name:             "short name with explicit version",
+			secretIdentifier: "my-secret",
+			version:          "5",
+			projectEnv:       "test-project-1",
+			wantName:         "projects/test-project-1/secrets/my-secret/versions/5",
+			wantErr:          false,
+		},
 		{
 			name:             "full path",
 			secretIdentifier: "projects/test-project-2/secrets/another-secret",
-			projectEnv:       "", 
+			projectEnv:       "",
 			wantName:         "projects/test-project-2/secrets/another-secret/versions/latest",
 			wantErr:          false,
 		},
 		{
-			name:             "full path with version specified (should use latest)",
+			name:             "full path with explicit version (honored verbatim)",
 			secretIdentifier: "projects/test-project-3/secrets/versioned-secret/versions/3",
-			projectEnv:       "", 
+			projectEnv:       "",
+			wantName:         "projects/test-project-3/secrets/versioned-secret/versions/3",
+			wantErr:          false,
+		},
+		{
+			name:             "full path with explicit latest (honored verbatim)",
+			secretIdentifier: "projects/test-project-3/secrets/versioned-secret/versions/latest",
+			projectEnv:       "",
 			wantName:         "projects/test-project-3/secrets/versioned-secret/versions/latest",
 			wantErr:          false,
 		},
+		{
+			name:             "explicit version suffix ignores a bogus -version flag",
+			secretIdentifier: "projects/test-project-3/secrets/versioned-secret/versions/3",
+			version:          "bogus",
+			projectEnv:       "",
+			wantName:         "projects/test-project-3/secrets/versioned-secret/versions/3",
+			wantErr:          false,
+		},
 		{
 			name:             "empty secret identifier",
 			secretIdentifier: "",
@@ -77,13 +133,6 @@ func TestBuildFullSecretVersionName(t *testing.T) {
 			wantErr:          true,
 			wantErrMsgPart:   "secret identifier cannot be empty",
 		},
-		{
-			name:             "short name without project env (expect error from getProjectID)",
-			secretIdentifier: "my-secret-no-env",
-			projectEnv:       "", 
-			wantErr:          true,
-			wantErrMsgPart:   "Could not determine Project ID", 
-		},
 		{
 			name:             "invalid full path format (too few parts)",
 			secretIdentifier: "projects/test-project-4",
@@ -98,6 +147,21 @@ func TestBuildFullSecretVersionName(t *testing.T) {
 			wantErr:          true,
 			wantErrMsgPart:   "invalid secret path format",
 		},
+		{
+			name:             "invalid version suffix",
+			secretIdentifier: "projects/test-project-6/secrets/my-secret/versions/not-a-version",
+			projectEnv:       "",
+			wantErr:          true,
+			wantErrMsgPart:   "invalid version suffix",
+		},
+		{
+			name:             "invalid version flag",
+			secretIdentifier: "my-secret",
+			version:          "not-a-version",
+			projectEnv:       "test-project-1",
+			wantErr:          true,
+			wantErrMsgPart:   "invalid version",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -108,7 +172,7 @@ func TestBuildFullSecretVersionName(t *testing.T) {
 				os.Unsetenv("GOOGLE_CLOUD_PROJECT")
 			}
 
-			gotName, err := buildFullSecretVersionName(ctx, tc.secretIdentifier)
+			gotName, err := buildFullSecretVersionName(ctx, tc.secretIdentifier, tc.version)
 
 			if tc.wantErr {
 				if err == nil {
@@ -127,3 +191,189 @@ func TestBuildFullSecretVersionName(t *testing.T) {
 		})
 	}
 }
+
+// TestBuildFullSecretVersionNameProjectUndetectable checks that a short-name
+// identifier fails fast with an actionable error when detectProjectID can't
+// resolve a project, rather than silently building a path around the
+// detectProjectIDSentinel. Like TestDetectProjectID's "falls back to
+// sentinel" case, this tolerates ADC or GCE metadata being configured in the
+// test environment, in which case detectProjectID resolves a real project
+// and there's nothing to assert here.
+func TestBuildFullSecretVersionNameProjectUndetectable(t *testing.T) {
+	ctx := context.Background()
+	originalGoogleCloudProject := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	originalGCPProject := os.Getenv("GCP_PROJECT")
+	defer func() {
+		os.Setenv("GOOGLE_CLOUD_PROJECT", originalGoogleCloudProject)
+		os.Setenv("GCP_PROJECT", originalGCPProject)
+	}()
+	os.Unsetenv("GOOGLE_CLOUD_PROJECT")
+	os.Unsetenv("GCP_PROJECT")
+
+	if projectID, err := detectProjectID(ctx); err == nil && projectID != detectProjectIDSentinel {
+		t.Skipf("ADC or GCE metadata resolved %q in this environment; nothing to assert", projectID)
+	}
+
+	_, err := buildFullSecretVersionName(ctx, "my-secret", "")
+	if err == nil {
+		t.Fatal("expected an error when the project can't be determined")
+	}
+	if !strings.Contains(err.Error(), "could not determine Project ID") {
+		t.Errorf("expected error to mention the project could not be determined, got: %v", err)
+	}
+}
+
+// TestAccessSecret tests accessSecret against a fakeSecretClient.
+func TestAccessSecret(t *testing.T) {
+	ctx := context.Background()
+	const fullName = "projects/test-project/secrets/my-secret/versions/latest"
+
+	testCases := []struct {
+		name           string
+		client         *fakeSecretClient
+		fullName       string
+		wantPayload    string
+		wantErr        bool
+		wantErrMsgPart string
+	}{
+		{
+			name:        "success",
+			client:      &fakeSecretClient{secrets: map[string]string{fullName: "s3cr3t"}},
+			fullName:    fullName,
+			wantPayload: "s3cr3t",
+		},
+		{
+			name:           "not found",
+			client:         &fakeSecretClient{secrets: map[string]string{}},
+			fullName:       fullName,
+			wantErr:        true,
+			wantErrMsgPart: "not found",
+		},
+		{
+			name:           "permission denied",
+			client:         &fakeSecretClient{returnError: status.Error(codes.PermissionDenied, "caller lacks permission")},
+			fullName:       fullName,
+			wantErr:        true,
+			wantErrMsgPart: "caller lacks permission",
+		},
+		{
+			name:           "nil payload",
+			client:         &fakeSecretClient{secrets: map[string]string{fullName: ""}, nilPayload: true},
+			fullName:       fullName,
+			wantErr:        true,
+			wantErrMsgPart: "payload is unexpectedly nil",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := accessSecret(ctx, tc.client, tc.fullName, clientConfig{})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got nil")
+				}
+				if !strings.Contains(err.Error(), tc.wantErrMsgPart) {
+					t.Errorf("expected error to contain %q, got: %v", tc.wantErrMsgPart, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(payload) != tc.wantPayload {
+				t.Errorf("expected payload %q, got %q", tc.wantPayload, payload)
+			}
+		})
+	}
+}
+
+// TestFakeSecretClientCloseError checks that fakeSecretClient.Close returns
+// its configured closeError and marks the client closed.
+func TestFakeSecretClientCloseError(t *testing.T) {
+	client := &fakeSecretClient{closeError: errors.New("close failed")}
+	if err := client.Close(); err == nil || !strings.Contains(err.Error(), "close failed") {
+		t.Errorf("expected Close to return the configured error, got: %v", err)
+	}
+	if !client.closed {
+		t.Errorf("expected client to be marked closed")
+	}
+}
+
+// TestRun tests the run entry point against a fakeSecretClient.
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+
+	const fullName = "projects/test-project/secrets/my-secret/versions/latest"
+	client := &fakeSecretClient{secrets: map[string]string{fullName: "s3cr3t"}}
+
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, client, clientConfig{}, []string{"-secret-identifier", "my-secret"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+	if stdout.String() != "s3cr3t" {
+		t.Errorf("expected stdout %q, got %q", "s3cr3t", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(ctx, client, clientConfig{}, []string{"-secret-identifier", ""}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("expected exit code 1 for empty identifier, got %d", code)
+	}
+}
+
+// TestRunRequireEnabled checks that -require-enabled rejects a resolved
+// secret version that is not ENABLED.
+func TestRunRequireEnabled(t *testing.T) {
+	ctx := context.Background()
+	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+
+	const fullName = "projects/test-project/secrets/my-secret/versions/7"
+	client := &fakeSecretClient{
+		secrets:       map[string]string{fullName: "s3cr3t"},
+		versionStates: map[string]secretmanagerpb.SecretVersion_State{fullName: secretmanagerpb.SecretVersion_DISABLED},
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, client, clientConfig{}, []string{"-secret-identifier", "my-secret", "-version", "7", "-require-enabled"}, &stdout, &stderr)
+	if code != 5 {
+		t.Fatalf("expected exit code 5 for disabled version, got %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "not enabled") {
+		t.Errorf("expected stderr to mention the version is not enabled, got: %s", stderr.String())
+	}
+}
+
+// TestRunBatchMode checks that repeated 'NAME=<identifier>' -secret-identifier
+// values dispatch to batch mode and are emitted in dotenv format by default.
+func TestRunBatchMode(t *testing.T) {
+	ctx := context.Background()
+	originalProjectEnv := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	defer os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectEnv)
+	os.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+
+	client := &fakeSecretClient{
+		secrets: map[string]string{
+			"projects/test-project/secrets/db-password/versions/latest": "hunter2",
+			"projects/test-project/secrets/api-key/versions/latest":     "abc123",
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, client, clientConfig{}, []string{
+		"-secret-identifier", "DB_PASSWORD=db-password",
+		"-secret-identifier", "API_KEY=api-key",
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "DB_PASSWORD=hunter2") || !strings.Contains(stdout.String(), "API_KEY=abc123") {
+		t.Errorf("unexpected batch output: %q", stdout.String())
+	}
+}